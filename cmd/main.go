@@ -5,18 +5,25 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/crane"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/hashicorp/go-multierror"
 	"github.com/networkteam/slogutils"
 	specsv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/urfave/cli/v2"
+	goyaml "gopkg.in/yaml.v3"
 
+	"github.com/networkteam/stacker/gitops"
 	"github.com/networkteam/stacker/yaml"
 )
 
@@ -35,6 +42,58 @@ func main() {
 			Aliases: []string{"vv"},
 			Usage:   "Enable super verbose logging",
 		},
+		&cli.StringFlag{
+			Name:  "default-target-tag-suffix",
+			Usage: "Suffix appended to the source tag to derive a push destination for annotations without an explicit $rebase target, e.g. \"-rebased\"",
+		},
+		&cli.StringFlag{
+			Name:  "config",
+			Usage: "Path to a stacker.yaml declaring JSONPath-based rebase rules (defaults to stacker.yaml in the walked directory, if present)",
+		},
+		&cli.BoolFlag{
+			Name:  "git-commit",
+			Usage: "Commit the files stacker modified to a dedicated branch",
+		},
+		&cli.BoolFlag{
+			Name:  "git-push",
+			Usage: "Push the commit created by --git-commit (implies --git-commit)",
+		},
+		&cli.StringFlag{
+			Name:  "git-branch",
+			Usage: "Branch to create or reset before committing (defaults to \"stacker/rebase-<timestamp>\")",
+		},
+		&cli.StringFlag{
+			Name:  "git-remote",
+			Usage: "Remote to push to",
+			Value: "origin",
+		},
+		&cli.StringFlag{
+			Name:  "git-author-name",
+			Usage: "Author/committer name for the --git-commit commit",
+			Value: "stacker",
+		},
+		&cli.StringFlag{
+			Name:  "git-author-email",
+			Usage: "Author/committer email for the --git-commit commit",
+			Value: "stacker@localhost",
+		},
+		&cli.BoolFlag{
+			Name:  "git-force-checkout",
+			Usage: "Check out --git-branch even if the worktree has uncommitted changes, discarding them",
+		},
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Compute rebases without pushing images or writing YAML back to disk",
+		},
+		&cli.StringFlag{
+			Name:  "report",
+			Usage: "Write a report of planned rebases to this path (implies --dry-run)",
+		},
+		&cli.StringFlag{
+			Name:  "report-format",
+			Usage: "Report format, \"json\" or \"sarif\"",
+			Value: "json",
+		},
 	}
 	app.ArgsUsage = "[directory]"
 	app.Before = func(c *cli.Context) error {
@@ -61,10 +120,33 @@ func main() {
 
 		directory := c.Args().First()
 
+		rebaseConfig, err := loadRebaseConfig(c.String("config"), directory)
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+
+		reportPath := c.String("report")
+		dryRun := c.Bool("dry-run") || reportPath != ""
+
+		var committer *gitops.Committer
+		if !dryRun && (c.Bool("git-commit") || c.Bool("git-push")) {
+			committer, err = gitops.Open(directory, gitops.Options{
+				Branch:      c.String("git-branch"),
+				Remote:      c.String("git-remote"),
+				AuthorName:  c.String("git-author-name"),
+				AuthorEmail: c.String("git-author-email"),
+				Force:       c.Bool("git-force-checkout"),
+			})
+			if err != nil {
+				return fmt.Errorf("opening git repository: %w", err)
+			}
+		}
+
 		var rebaseErr error
+		var report Report
 
 		// Find YAML files in directory
-		err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+		err = filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
 			}
@@ -85,17 +167,59 @@ func main() {
 
 			ctx := slogutils.WithLogger(c.Context, slog.With("file", relPath))
 
-			err = processRebaseAnnotations(ctx, path)
+			if dryRun {
+				plans, err := planRebaseAnnotations(ctx, path, rebaseConfig)
+				if err != nil {
+					rebaseErr = multierror.Append(rebaseErr, fmt.Errorf("planning %s: %w", relPath, err))
+				}
+				if len(plans) > 0 {
+					report.Files = append(report.Files, FileReport{File: relPath, Plans: plans})
+				}
+
+				return nil
+			}
+
+			events, err := processRebaseAnnotations(ctx, path, c.String("default-target-tag-suffix"), rebaseConfig)
 			if err != nil {
 				rebaseErr = multierror.Append(rebaseErr, fmt.Errorf("processing %s: %w", relPath, err))
 			}
 
+			if committer != nil && len(events) > 0 {
+				if err := committer.Track(path, events); err != nil {
+					rebaseErr = multierror.Append(rebaseErr, fmt.Errorf("tracking %s for git commit: %w", relPath, err))
+				}
+			}
+
 			return nil
 		})
 		if err != nil {
 			return multierror.Append(rebaseErr, fmt.Errorf("walking directory: %w", err))
 		}
 
+		if dryRun {
+			if reportPath != "" {
+				if err := writeReport(report, reportPath, c.String("report-format")); err != nil {
+					rebaseErr = multierror.Append(rebaseErr, fmt.Errorf("writing report: %w", err))
+				}
+			} else if err := encodeReport(report, os.Stdout, c.String("report-format")); err != nil {
+				rebaseErr = multierror.Append(rebaseErr, fmt.Errorf("writing report to stdout: %w", err))
+			}
+		}
+
+		if committer != nil {
+			// The YAML changes are already on disk at this point, so a
+			// failure here is reported but does not undo them.
+			if err := committer.Commit(c.Context, time.Now()); err != nil {
+				return multierror.Append(rebaseErr, fmt.Errorf("committing rebased files: %w", err))
+			}
+
+			if c.Bool("git-push") {
+				if err := committer.Push(c.Context); err != nil {
+					return multierror.Append(rebaseErr, fmt.Errorf("pushing rebased files: %w", err))
+				}
+			}
+		}
+
 		return rebaseErr
 	}
 
@@ -106,34 +230,66 @@ func main() {
 	}
 }
 
-func processRebaseAnnotations(ctx context.Context, path string) error {
+// loadRebaseConfig reads JSONPath-based rebase rules from configPath. If
+// configPath is empty, it falls back to a "stacker.yaml" in directory, which
+// is optional; any other configPath is required to exist.
+func loadRebaseConfig(configPath, directory string) (yaml.RebaseConfig, error) {
+	explicit := configPath != ""
+	if !explicit {
+		configPath = filepath.Join(directory, "stacker.yaml")
+	}
+
+	b, err := os.ReadFile(configPath)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return yaml.RebaseConfig{}, nil
+		}
+
+		return yaml.RebaseConfig{}, fmt.Errorf("reading %s: %w", configPath, err)
+	}
+
+	var cfg yaml.RebaseConfig
+	if err := goyaml.Unmarshal(b, &cfg); err != nil {
+		return yaml.RebaseConfig{}, fmt.Errorf("parsing %s: %w", configPath, err)
+	}
+
+	return cfg, nil
+}
+
+func processRebaseAnnotations(ctx context.Context, path string, defaultTargetTagSuffix string, rebaseConfig yaml.RebaseConfig) ([]gitops.RebasedEvent, error) {
 	logger := slogutils.FromContext(ctx)
 
 	logger.Log(ctx, slogutils.LevelTrace, "Checking for annotations in file")
 
 	f, err := os.OpenFile(path, os.O_RDWR, 0)
 	if err != nil {
-		return fmt.Errorf("opening file: %w", err)
+		return nil, fmt.Errorf("opening file: %w", err)
 	}
 	defer f.Close()
 
 	patcher, err := yaml.NewProcessor(f)
 	if err != nil {
-		return fmt.Errorf("opening YAML: %w", err)
+		return nil, fmt.Errorf("opening YAML: %w", err)
 	}
 
 	annotations, err := patcher.FindRebaseAnnotations()
 	if err != nil {
-		return fmt.Errorf("finding rebase annotations: %w", err)
+		return nil, fmt.Errorf("finding rebase annotations: %w", err)
+	}
+
+	configAnnotations, err := patcher.FindRebaseAnnotationsFromConfig(rebaseConfig)
+	if err != nil {
+		return nil, fmt.Errorf("finding rebase annotations from config: %w", err)
 	}
+	annotations = append(annotations, configAnnotations...)
 
 	// Process annotations
 
 	var rebaseErr error
-	var didRebaseAny bool
+	var events []gitops.RebasedEvent
 
 	for _, annotation := range annotations {
-		newDigest, didRebase, err := processRebaseAnnotation(ctx, annotation)
+		newDigest, oldDigest, didRebase, err := processRebaseAnnotation(ctx, annotation, defaultTargetTagSuffix)
 		if err != nil {
 			rebaseErr = multierror.Append(rebaseErr, fmt.Errorf("rebasing image %s:%s: %w", annotation.Name, annotation.TagWithoutDigest(), err))
 		}
@@ -143,114 +299,495 @@ func processRebaseAnnotations(ctx context.Context, path string) error {
 		}
 
 		logger.Info("Rebased image", "image", annotation.Name, "tag", annotation.TagWithoutDigest(), "newDigest", newDigest)
-		didRebaseAny = true
 
 		annotation.UpdateTagDigest(newDigest)
+		events = append(events, gitops.RebasedEvent{
+			File:      path,
+			Image:     annotation.Name,
+			OldDigest: oldDigest,
+			NewDigest: newDigest,
+		})
 	}
 
-	if didRebaseAny {
+	if len(events) > 0 {
 		// Write back to file by calling Encode from patcher to file
 
 		err = f.Truncate(0)
 		if err != nil {
-			return fmt.Errorf("truncating file: %w", err)
+			return nil, fmt.Errorf("truncating file: %w", err)
 		}
 		_, err = f.Seek(0, 0)
 		if err != nil {
-			return fmt.Errorf("seeking to beginning of file: %w", err)
+			return nil, fmt.Errorf("seeking to beginning of file: %w", err)
 		}
 
 		err = patcher.Encode(f)
 		if err != nil {
-			return fmt.Errorf("encoding YAML back to file: %w", err)
+			return nil, fmt.Errorf("encoding YAML back to file: %w", err)
 		}
 
 		logger.Info("Wrote back updated YAML to file")
 	}
 
-	return rebaseErr
+	return events, rebaseErr
 }
 
-func processRebaseAnnotation(ctx context.Context, annotation yaml.RebaseAnnotation) (string, bool, error) {
-	logger := slogutils.FromContext(ctx).With("image", annotation.Name, "tag", annotation.TagWithoutDigest())
+// planRebaseAnnotations is the --dry-run counterpart of
+// processRebaseAnnotations: it finds the same annotations and computes the
+// same rebase plans, but only reads the file and never writes anything back.
+func planRebaseAnnotations(ctx context.Context, path string, rebaseConfig yaml.RebaseConfig) ([]RebasePlan, error) {
+	logger := slogutils.FromContext(ctx)
 
-	logger.Debug("Rebasing")
+	logger.Log(ctx, slogutils.LevelTrace, "Checking for annotations in file")
 
-	var oldBase, newBase, rebased string
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening file: %w", err)
+	}
+	defer f.Close()
 
-	orig := fmt.Sprintf("%s:%s", annotation.Name, annotation.TagWithoutDigest())
-	// For now the target is always the same image and tag
-	rebased = orig
+	patcher, err := yaml.NewProcessor(f)
+	if err != nil {
+		return nil, fmt.Errorf("opening YAML: %w", err)
+	}
 
-	r, err := name.ParseReference(rebased)
+	annotations, err := patcher.FindRebaseAnnotations()
 	if err != nil {
-		return "", false, fmt.Errorf("parsing rebased reference: %w", err)
+		return nil, fmt.Errorf("finding rebase annotations: %w", err)
+	}
+
+	configAnnotations, err := patcher.FindRebaseAnnotationsFromConfig(rebaseConfig)
+	if err != nil {
+		return nil, fmt.Errorf("finding rebase annotations from config: %w", err)
+	}
+	annotations = append(annotations, configAnnotations...)
+
+	var rebaseErr error
+	var plans []RebasePlan
+
+	for _, annotation := range annotations {
+		plan, err := planRebaseAnnotation(ctx, annotation)
+		if err != nil {
+			rebaseErr = multierror.Append(rebaseErr, fmt.Errorf("planning rebase of image %s:%s: %w", annotation.Name, annotation.TagWithoutDigest(), err))
+			continue
+		}
+
+		plans = append(plans, plan)
+	}
+
+	return plans, rebaseErr
+}
+
+// targetName returns the push destination's image name: the annotation's
+// explicit target name, or the source name if none was given.
+func targetName(annotation yaml.RebaseAnnotation) string {
+	if annotation.TargetName != "" {
+		return annotation.TargetName
+	}
+
+	return annotation.Name
+}
+
+// targetTag returns the push destination's tag: the annotation's explicit
+// target tag, or the source tag suffixed with defaultTargetTagSuffix when the
+// annotation declares no explicit target at all.
+func targetTag(annotation yaml.RebaseAnnotation, defaultTargetTagSuffix string) string {
+	if annotation.TargetTag != "" {
+		return annotation.TargetTagWithoutDigest()
 	}
 
+	hasExplicitTarget := annotation.TargetName != "" || annotation.TargetTag != ""
+	if !hasExplicitTarget && defaultTargetTagSuffix != "" {
+		return annotation.TagWithoutDigest() + defaultTargetTagSuffix
+	}
+
+	return annotation.TagWithoutDigest()
+}
+
+// planRebaseAnnotation computes a RebasePlan for annotation without pushing
+// anything, dispatching to PlanRebase or PlanRebaseIndex depending on the
+// media type of the current image.
+func planRebaseAnnotation(ctx context.Context, annotation yaml.RebaseAnnotation) (RebasePlan, error) {
+	orig := fmt.Sprintf("%s:%s", annotation.Name, annotation.TagWithoutDigest())
+
 	desc, err := crane.Head(orig)
 	if err != nil {
-		return "", false, fmt.Errorf("checking: %w", err)
+		return RebasePlan{}, fmt.Errorf("checking: %w", err)
 	}
 
 	if desc.MediaType.IsIndex() {
-		return "", false, errors.New("rebasing an index is not yet supported")
+		return PlanRebaseIndex(ctx, annotation)
 	}
 
+	return PlanRebase(ctx, annotation)
+}
+
+// PlanRebase performs all registry lookups and computes the rebased image
+// in-memory via mutate.Rebase, without pushing it or writing anything back to
+// disk. It is the pure computation shared by the normal rebase flow and
+// --dry-run reporting.
+func PlanRebase(ctx context.Context, annotation yaml.RebaseAnnotation) (RebasePlan, error) {
+	orig := fmt.Sprintf("%s:%s", annotation.Name, annotation.TagWithoutDigest())
+
 	// This is from `crane rebase`
 
 	origImg, err := crane.Pull(orig)
 	if err != nil {
-		return "", false, fmt.Errorf("pulling image: %w", err)
+		return RebasePlan{}, fmt.Errorf("pulling image: %w", err)
 	}
 	origMf, err := origImg.Manifest()
 	if err != nil {
-		return "", false, fmt.Errorf("getting manifest: %w", err)
+		return RebasePlan{}, fmt.Errorf("getting manifest: %w", err)
 	}
 	anns := origMf.Annotations
-	newBase = anns[specsv1.AnnotationBaseImageName]
+	newBase := anns[specsv1.AnnotationBaseImageName]
 	if newBase == "" {
-		return "", false, errors.New("could not determine new base image from annotations")
+		return RebasePlan{}, errors.New("could not determine new base image from annotations")
 	}
 	newBaseRef, err := name.ParseReference(newBase)
 	if err != nil {
-		return "", false, fmt.Errorf("parsing new base reference: %w", err)
+		return RebasePlan{}, fmt.Errorf("parsing new base reference: %w", err)
 	}
 	oldBaseDigest := anns[specsv1.AnnotationBaseImageDigest]
-	oldBase = newBaseRef.Context().Digest(oldBaseDigest).String()
+	oldBase := newBaseRef.Context().Digest(oldBaseDigest).String()
 	if oldBase == "" {
-		return "", false, errors.New("could not determine old base image by digest from annotations")
+		return RebasePlan{}, errors.New("could not determine old base image by digest from annotations")
 	}
 
 	rebasedImg, err := rebaseImage(ctx, origImg, oldBase, newBase)
 	if err != nil {
-		return "", false, fmt.Errorf("rebasing image: %w", err)
+		return RebasePlan{}, fmt.Errorf("rebasing image: %w", err)
 	}
 
 	rebasedDigest, err := rebasedImg.Digest()
 	if err != nil {
-		return "", false, fmt.Errorf("digesting new image: %w", err)
+		return RebasePlan{}, fmt.Errorf("digesting new image: %w", err)
 	}
 	origDigest, err := origImg.Digest()
 	if err != nil {
-		return "", false, fmt.Errorf("digesting old image: %w", err)
+		return RebasePlan{}, fmt.Errorf("digesting old image: %w", err)
+	}
+
+	newBaseDesc, err := crane.Head(newBase)
+	if err != nil {
+		return RebasePlan{}, fmt.Errorf("getting new base image digest: %w", err)
+	}
+
+	return RebasePlan{
+		Identifier:    annotation.Identifier,
+		Image:         annotation.Name,
+		Tag:           annotation.TagWithoutDigest(),
+		CurrentDigest: origDigest.String(),
+		NewBase:       newBase,
+		NewBaseDigest: newBaseDesc.Digest.String(),
+		ResultDigest:  rebasedDigest.String(),
+		NoOp:          rebasedDigest == origDigest,
+		rebasedImg:    rebasedImg,
+	}, nil
+}
+
+// PlanRebaseIndex is the multi-architecture counterpart of PlanRebase: it
+// rebases every platform-specific child of the index in-memory against the
+// platform-matched child of the new base index and reassembles a new index,
+// without pushing it.
+func PlanRebaseIndex(ctx context.Context, annotation yaml.RebaseAnnotation) (RebasePlan, error) {
+	orig := fmt.Sprintf("%s:%s", annotation.Name, annotation.TagWithoutDigest())
+
+	origRef, err := name.ParseReference(orig)
+	if err != nil {
+		return RebasePlan{}, fmt.Errorf("parsing original reference: %w", err)
+	}
+
+	origIdx, err := remote.Index(origRef)
+	if err != nil {
+		return RebasePlan{}, fmt.Errorf("pulling index: %w", err)
+	}
+	origIdxManifest, err := origIdx.IndexManifest()
+	if err != nil {
+		return RebasePlan{}, fmt.Errorf("getting index manifest: %w", err)
+	}
+	if len(origIdxManifest.Manifests) == 0 {
+		return RebasePlan{}, errors.New("index has no manifests")
+	}
+
+	// The new base is determined from the base image annotations of a child
+	// manifest, as the top-level index itself carries no annotations.
+	// Attestation manifests (unknown/unknown platform) carry no such
+	// annotations, so the first one with a known platform is used instead of
+	// blindly taking the first entry in the index.
+	firstChild, err := firstKnownPlatformChild(origIdx, origIdxManifest.Manifests)
+	if err != nil {
+		return RebasePlan{}, err
+	}
+	firstChildMf, err := firstChild.Manifest()
+	if err != nil {
+		return RebasePlan{}, fmt.Errorf("getting child manifest: %w", err)
+	}
+	newBase := firstChildMf.Annotations[specsv1.AnnotationBaseImageName]
+	if newBase == "" {
+		return RebasePlan{}, errors.New("could not determine new base image from annotations")
+	}
+	newBaseRef, err := name.ParseReference(newBase)
+	if err != nil {
+		return RebasePlan{}, fmt.Errorf("parsing new base reference: %w", err)
+	}
+
+	newBaseIdx, err := remote.Index(newBaseRef)
+	if err != nil {
+		return RebasePlan{}, fmt.Errorf("pulling new base index: %w", err)
+	}
+	newBaseIdxManifest, err := newBaseIdx.IndexManifest()
+	if err != nil {
+		return RebasePlan{}, fmt.Errorf("getting new base index manifest: %w", err)
+	}
+	// The digest recorded as the new base, on the children and on the
+	// rebased index, is the head digest of the new base index itself rather
+	// than that of a platform-specific child.
+	newBaseDesc, err := crane.Head(newBase)
+	if err != nil {
+		return RebasePlan{}, fmt.Errorf("getting new base image digest: %w", err)
+	}
+	newBaseDigest := newBaseDesc.Digest.String()
+
+	origDigest, err := origIdx.Digest()
+	if err != nil {
+		return RebasePlan{}, fmt.Errorf("digesting old index: %w", err)
+	}
+
+	// Short-circuit if the index is already based on the current new base:
+	// reassembling and re-digesting an unchanged index below would not
+	// reliably detect a no-op, as mutate re-serializes every manifest and so
+	// rarely reproduces the original bytes (and therefore digest) even when
+	// nothing logically changes.
+	if firstChildMf.Annotations[specsv1.AnnotationBaseImageDigest] == newBaseDigest {
+		return RebasePlan{
+			Identifier:    annotation.Identifier,
+			Image:         annotation.Name,
+			Tag:           annotation.TagWithoutDigest(),
+			CurrentDigest: origDigest.String(),
+			NewBase:       newBase,
+			NewBaseDigest: newBaseDigest,
+			ResultDigest:  origDigest.String(),
+			NoOp:          true,
+		}, nil
+	}
+
+	var adds []mutate.IndexAddendum
+	var attestationDescs []v1.Descriptor
+	// rebasedDigests maps each platform child's pre-rebase digest to its
+	// post-rebase digest, so attestation manifests referencing a platform
+	// child by digest (below) can be updated to still point at it.
+	rebasedDigests := make(map[string]string)
+
+	for _, childDesc := range origIdxManifest.Manifests {
+		platform := childDesc.Platform
+		if platform == nil {
+			return RebasePlan{}, fmt.Errorf("child manifest %s has no platform", childDesc.Digest)
+		}
+
+		if isUnknownPlatform(platform) {
+			// Attestation manifests (e.g. buildx provenance/SBOM
+			// attachments) are published with platform unknown/unknown, have
+			// no runnable content and carry no base image annotations, so
+			// they are passed through unchanged instead of being rebased.
+			// Their reference-digest annotation is fixed up in a second pass
+			// below, once every platform child has been rebased.
+			attestationDescs = append(attestationDescs, childDesc)
+			continue
+		}
+
+		childImg, err := origIdx.Image(childDesc.Digest)
+		if err != nil {
+			return RebasePlan{}, fmt.Errorf("pulling child image for %s: %w", platformString(platform), err)
+		}
+		childMf, err := childImg.Manifest()
+		if err != nil {
+			return RebasePlan{}, fmt.Errorf("getting child manifest for %s: %w", platformString(platform), err)
+		}
+
+		oldBaseDigest := childMf.Annotations[specsv1.AnnotationBaseImageDigest]
+		if oldBaseDigest == "" {
+			return RebasePlan{}, fmt.Errorf("could not determine old base image by digest from annotations for %s", platformString(platform))
+		}
+		oldBase := newBaseRef.Context().Digest(oldBaseDigest).String()
+
+		newBaseChildDesc := matchPlatform(newBaseIdxManifest.Manifests, platform)
+		if newBaseChildDesc == nil {
+			return RebasePlan{}, fmt.Errorf("no matching platform %s in new base index", platformString(platform))
+		}
+
+		oldBaseImg, err := crane.Pull(oldBase, crane.WithPlatform(platform))
+		if err != nil {
+			return RebasePlan{}, fmt.Errorf("pulling old base image for %s: %w", platformString(platform), err)
+		}
+		newBaseChildImg, err := newBaseIdx.Image(newBaseChildDesc.Digest)
+		if err != nil {
+			return RebasePlan{}, fmt.Errorf("pulling new base image for %s: %w", platformString(platform), err)
+		}
+
+		rebasedChildImg, err := mutate.Rebase(childImg, oldBaseImg, newBaseChildImg)
+		if err != nil {
+			return RebasePlan{}, fmt.Errorf("rebasing image for %s: %w", platformString(platform), err)
+		}
+
+		rebasedChildImg = mutate.Annotations(rebasedChildImg, map[string]string{
+			specsv1.AnnotationBaseImageDigest: newBaseDigest,
+			specsv1.AnnotationBaseImageName:   newBase,
+		}).(v1.Image)
+
+		rebasedChildDigest, err := rebasedChildImg.Digest()
+		if err != nil {
+			return RebasePlan{}, fmt.Errorf("digesting rebased image for %s: %w", platformString(platform), err)
+		}
+		rebasedDigests[childDesc.Digest.String()] = rebasedChildDigest.String()
+
+		adds = append(adds, mutate.IndexAddendum{
+			Add:        rebasedChildImg,
+			Descriptor: v1.Descriptor{Platform: platform},
+		})
+	}
+
+	for _, attestationDesc := range attestationDescs {
+		passthroughImg, err := origIdx.Image(attestationDesc.Digest)
+		if err != nil {
+			return RebasePlan{}, fmt.Errorf("pulling passthrough manifest %s: %w", attestationDesc.Digest, err)
+		}
+
+		// The manifest this attestation is attached to is recorded by
+		// digest in this annotation; rewrite it so the attestation still
+		// refers to its subject now that the subject has been rebased.
+		if refDigest, ok := attestationDesc.Annotations[dockerReferenceDigestAnnotation]; ok {
+			if rebasedDigest, ok := rebasedDigests[refDigest]; ok {
+				attestationDesc.Annotations = maps.Clone(attestationDesc.Annotations)
+				attestationDesc.Annotations[dockerReferenceDigestAnnotation] = rebasedDigest
+			}
+		}
+
+		adds = append(adds, mutate.IndexAddendum{
+			Add:        passthroughImg,
+			Descriptor: attestationDesc,
+		})
+	}
+
+	rebasedIdx := mutate.AppendManifests(empty.Index, adds...)
+	rebasedIdx = mutate.Annotations(rebasedIdx, map[string]string{
+		specsv1.AnnotationBaseImageDigest: newBaseDigest,
+		specsv1.AnnotationBaseImageName:   newBase,
+	}).(v1.ImageIndex)
+
+	rebasedDigest, err := rebasedIdx.Digest()
+	if err != nil {
+		return RebasePlan{}, fmt.Errorf("digesting new index: %w", err)
+	}
+
+	return RebasePlan{
+		Identifier:    annotation.Identifier,
+		Image:         annotation.Name,
+		Tag:           annotation.TagWithoutDigest(),
+		CurrentDigest: origDigest.String(),
+		NewBase:       newBase,
+		NewBaseDigest: newBaseDigest,
+		ResultDigest:  rebasedDigest.String(),
+		NoOp:          false,
+		rebasedIdx:    rebasedIdx,
+	}, nil
+}
+
+// isUnknownPlatform reports whether platform is the "unknown/unknown"
+// placeholder used by buildx for non-runnable manifests (attestations,
+// provenance, SBOMs) attached to a multi-architecture index.
+func isUnknownPlatform(p *v1.Platform) bool {
+	return p.OS == "unknown" && p.Architecture == "unknown"
+}
+
+// dockerReferenceDigestAnnotation is set by buildx on an attestation
+// manifest's index descriptor to record, by digest, the platform manifest it
+// was generated for.
+const dockerReferenceDigestAnnotation = "vnd.docker.reference.digest"
+
+// firstKnownPlatformChild returns the first manifest in manifests whose
+// platform is not the unknown/unknown attestation placeholder, as those
+// manifests carry no base image annotations to seed the new base from.
+func firstKnownPlatformChild(idx v1.ImageIndex, manifests []v1.Descriptor) (v1.Image, error) {
+	for _, m := range manifests {
+		if m.Platform == nil || isUnknownPlatform(m.Platform) {
+			continue
+		}
+
+		img, err := idx.Image(m.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("pulling child image for %s: %w", platformString(m.Platform), err)
+		}
+
+		return img, nil
+	}
+
+	return nil, errors.New("index has no child manifest with a known platform to determine the new base image from")
+}
+
+func processRebaseAnnotation(ctx context.Context, annotation yaml.RebaseAnnotation, defaultTargetTagSuffix string) (newDigest, oldDigest string, didRebase bool, err error) {
+	logger := slogutils.FromContext(ctx).With("image", annotation.Name, "tag", annotation.TagWithoutDigest())
+
+	logger.Debug("Rebasing")
+
+	plan, err := planRebaseAnnotation(ctx, annotation)
+	if err != nil {
+		return "", "", false, err
 	}
 
-	// Check if the image was rebased or we had a no-op rebase
-	if rebasedDigest == origDigest {
-		return rebasedDigest.String(), false, nil
+	if plan.NoOp {
+		return plan.ResultDigest, plan.CurrentDigest, false, nil
 	}
 
+	rebased := fmt.Sprintf("%s:%s", targetName(annotation), targetTag(annotation, defaultTargetTagSuffix))
+	r, err := name.ParseReference(rebased)
+	if err != nil {
+		return "", "", false, fmt.Errorf("parsing rebased reference: %w", err)
+	}
 	if _, ok := r.(name.Digest); ok {
-		rebased = r.Context().Digest(rebasedDigest.String()).String()
+		rebased = r.Context().Digest(plan.ResultDigest).String()
+		r, err = name.ParseReference(rebased)
+		if err != nil {
+			return "", "", false, fmt.Errorf("parsing rebased reference: %w", err)
+		}
 	}
 
-	logger.Debug("Pushing rebased image as", "rebased", rebased)
-	err = crane.Push(rebasedImg, rebased)
+	if plan.rebasedIdx != nil {
+		logger.Debug("Pushing rebased index as", "rebased", rebased)
+		err = remote.WriteIndex(r, plan.rebasedIdx, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	} else {
+		logger.Debug("Pushing rebased image as", "rebased", rebased)
+		err = crane.Push(plan.rebasedImg, rebased)
+	}
 	if err != nil {
-		return "", false, fmt.Errorf("pushing %s: %v", rebased, err)
+		return "", "", false, fmt.Errorf("pushing %s: %v", rebased, err)
+	}
+
+	return plan.ResultDigest, plan.CurrentDigest, true, nil
+}
+
+// matchPlatform finds the descriptor in manifests whose platform matches the
+// given platform's OS, architecture and variant.
+func matchPlatform(manifests []v1.Descriptor, platform *v1.Platform) *v1.Descriptor {
+	for i := range manifests {
+		p := manifests[i].Platform
+		if p == nil {
+			continue
+		}
+		if p.OS == platform.OS && p.Architecture == platform.Architecture && p.Variant == platform.Variant {
+			return &manifests[i]
+		}
+	}
+
+	return nil
+}
+
+func platformString(p *v1.Platform) string {
+	if p.Variant != "" {
+		return fmt.Sprintf("%s/%s/%s", p.OS, p.Architecture, p.Variant)
 	}
 
-	return rebasedDigest.String(), true, nil
+	return fmt.Sprintf("%s/%s", p.OS, p.Architecture)
 }
 
 // rebaseImage parses the references and uses them to perform a rebase on the
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/networkteam/stacker/yaml"
+)
+
+func TestTargetName(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation yaml.RebaseAnnotation
+		want       string
+	}{
+		{
+			name:       "no target name falls back to source name",
+			annotation: yaml.RebaseAnnotation{Name: "my.registry.com/project/app"},
+			want:       "my.registry.com/project/app",
+		},
+		{
+			name:       "explicit target name wins",
+			annotation: yaml.RebaseAnnotation{Name: "my.registry.com/project/app", TargetName: "other.registry.com/project/app"},
+			want:       "other.registry.com/project/app",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, targetName(tt.annotation))
+		})
+	}
+}
+
+func TestTargetTag(t *testing.T) {
+	tests := []struct {
+		name                   string
+		annotation             yaml.RebaseAnnotation
+		defaultTargetTagSuffix string
+		want                   string
+	}{
+		{
+			name:       "no target tag and no suffix falls back to source tag",
+			annotation: yaml.RebaseAnnotation{Tag: "1.2.3"},
+			want:       "1.2.3",
+		},
+		{
+			name:       "explicit target tag wins",
+			annotation: yaml.RebaseAnnotation{Tag: "1.2.3", TargetTag: "rebased"},
+			want:       "rebased",
+		},
+		{
+			name:       "explicit target tag strips a trailing digest",
+			annotation: yaml.RebaseAnnotation{Tag: "1.2.3", TargetTag: "rebased@sha256:d7500ff35777c1835490fb5d4bd5283236c9d18cdc59858c3203eda82abab412"},
+			want:       "rebased",
+		},
+		{
+			name:                   "default suffix applies when no explicit target is set",
+			annotation:             yaml.RebaseAnnotation{Tag: "1.2.3"},
+			defaultTargetTagSuffix: "-rebased",
+			want:                   "1.2.3-rebased",
+		},
+		{
+			name:                   "default suffix is ignored when target-name is set",
+			annotation:             yaml.RebaseAnnotation{Tag: "1.2.3", TargetName: "other.registry.com/project/app"},
+			defaultTargetTagSuffix: "-rebased",
+			want:                   "1.2.3",
+		},
+		{
+			name:                   "default suffix is ignored when target-tag is set",
+			annotation:             yaml.RebaseAnnotation{Tag: "1.2.3", TargetTag: "rebased"},
+			defaultTargetTagSuffix: "-rebased",
+			want:                   "rebased",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, targetTag(tt.annotation, tt.defaultTargetTagSuffix))
+		})
+	}
+}
+
+func TestIsUnknownPlatform(t *testing.T) {
+	tests := []struct {
+		name     string
+		platform *v1.Platform
+		want     bool
+	}{
+		{name: "unknown/unknown is unknown", platform: &v1.Platform{OS: "unknown", Architecture: "unknown"}, want: true},
+		{name: "linux/amd64 is not unknown", platform: &v1.Platform{OS: "linux", Architecture: "amd64"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isUnknownPlatform(tt.platform))
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// RebasePlan is the result of computing a rebase in-memory, without pushing
+// it anywhere. It is shared by the normal rebase flow, which pushes it if
+// NoOp is false, and --dry-run reporting, which never pushes it.
+type RebasePlan struct {
+	Identifier    string `json:"identifier"`
+	Image         string `json:"image"`
+	Tag           string `json:"tag"`
+	CurrentDigest string `json:"currentDigest"`
+	NewBase       string `json:"newBase"`
+	NewBaseDigest string `json:"newBaseDigest"`
+	ResultDigest  string `json:"resultDigest"`
+	NoOp          bool   `json:"noOp"`
+
+	// rebasedImg and rebasedIdx carry the already-computed rebase result
+	// through to the push step, so it does not need to be recomputed. At most
+	// one of them is set, depending on whether the original image was a
+	// single-platform image or a multi-architecture index; neither is set for
+	// an index that is already based on the current new base.
+	rebasedImg v1.Image
+	rebasedIdx v1.ImageIndex
+}
+
+// FileReport groups the RebasePlans found in a single YAML file.
+type FileReport struct {
+	File  string       `json:"file"`
+	Plans []RebasePlan `json:"plans"`
+}
+
+// Report is the top-level structure written by --report.
+type Report struct {
+	Files []FileReport `json:"files"`
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// sarifLog is a minimal subset of the SARIF 2.1.0 schema, sufficient to
+// surface rebase plans to tools that consume SARIF (e.g. GitHub code
+// scanning).
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name string `json:"name"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// WriteSARIF writes the report as a SARIF log, with a "note"-level result for
+// no-op rebases and a "warning"-level result for pending ones.
+func (r Report) WriteSARIF(w io.Writer) error {
+	var results []sarifResult
+
+	for _, file := range r.Files {
+		for _, plan := range file.Plans {
+			level := "warning"
+			text := fmt.Sprintf("%s:%s can be rebased onto %s (%s -> %s)", plan.Image, plan.Tag, plan.NewBase, plan.CurrentDigest, plan.ResultDigest)
+			if plan.NoOp {
+				level = "note"
+				text = fmt.Sprintf("%s:%s is already up to date with %s", plan.Image, plan.Tag, plan.NewBase)
+			}
+
+			results = append(results, sarifResult{
+				RuleID:  plan.Identifier,
+				Level:   level,
+				Message: sarifMessage{Text: text},
+				Locations: []sarifLocation{
+					{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: file.File}}},
+				},
+			})
+		}
+	}
+
+	log := sarifLog{
+		Schema:  "https://raw.githubusercontent.com/oasis-tcs/sarif-spec/master/Schemata/sarif-schema-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{
+			{
+				Tool:    sarifTool{Driver: sarifDriver{Name: "stacker"}},
+				Results: results,
+			},
+		},
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
+
+// encodeReport writes report to w in the given format ("json", the default,
+// or "sarif").
+func encodeReport(report Report, w io.Writer, format string) error {
+	switch format {
+	case "", "json":
+		return report.WriteJSON(w)
+	case "sarif":
+		return report.WriteSARIF(w)
+	default:
+		return fmt.Errorf("unknown report format %q", format)
+	}
+}
+
+// writeReport writes report to path in the given format ("json", the
+// default, or "sarif").
+func writeReport(report Report, path, format string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating report file: %w", err)
+	}
+	defer f.Close()
+
+	return encodeReport(report, f, format)
+}
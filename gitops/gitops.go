@@ -0,0 +1,269 @@
+// Package gitops provides an opt-in subsystem that commits (and optionally
+// pushes) the YAML files stacker has rebased, so users don't have to do so
+// by hand after every run.
+package gitops
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/hashicorp/go-multierror"
+)
+
+// defaultAuthorName and defaultAuthorEmail are used for the commit created by
+// Commit when Options.AuthorName/AuthorEmail are not set, so committing does
+// not depend on ambient git config (user.name/user.email) being present —
+// notably in the CI environments this feature targets.
+const (
+	defaultAuthorName  = "stacker"
+	defaultAuthorEmail = "stacker@localhost"
+)
+
+// RebasedEvent describes a single image rebase that was written back to a
+// YAML file, for summarizing in a commit message or notifying a hook.
+type RebasedEvent struct {
+	File      string
+	Image     string
+	OldDigest string
+	NewDigest string
+}
+
+// Notifier is notified about each rebase once it has been committed, so a
+// future hook (e.g. opening a GitHub PR) can be plugged in without changing
+// the rebase core.
+type Notifier interface {
+	Rebased(ctx context.Context, event RebasedEvent) error
+}
+
+// Options configures the opt-in git integration.
+type Options struct {
+	// Branch is the branch to create or reset before committing. Defaults to
+	// "stacker/rebase-<unix timestamp>" if empty.
+	Branch string
+	// Remote is the git remote to push to. Defaults to "origin".
+	Remote string
+	// AuthorName and AuthorEmail are used as both author and committer of the
+	// commit created by Commit. Default to "stacker" and
+	// "stacker@localhost".
+	AuthorName  string
+	AuthorEmail string
+	// Force checks out Branch even if the worktree has uncommitted changes,
+	// discarding them. Defaults to false, so Open fails instead of silently
+	// losing local changes that predate stacker's run.
+	Force bool
+	// Notifier, if set, is called for every rebase once it has been
+	// committed.
+	Notifier Notifier
+}
+
+// Committer tracks files modified by stacker during a single run and commits
+// (and optionally pushes) them to a dedicated branch.
+type Committer struct {
+	opts Options
+
+	repo     *git.Repository
+	worktree *git.Worktree
+
+	events []RebasedEvent
+	files  map[string]struct{}
+}
+
+// Open opens the git repository enclosing directory and checks out (creating
+// or resetting) the configured branch.
+func Open(directory string, opts Options) (*Committer, error) {
+	if opts.Remote == "" {
+		opts.Remote = "origin"
+	}
+	if opts.Branch == "" {
+		opts.Branch = fmt.Sprintf("stacker/rebase-%d", time.Now().Unix())
+	}
+	if opts.AuthorName == "" {
+		opts.AuthorName = defaultAuthorName
+	}
+	if opts.AuthorEmail == "" {
+		opts.AuthorEmail = defaultAuthorEmail
+	}
+
+	repo, err := git.PlainOpenWithOptions(directory, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("opening repository: %w", err)
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("getting worktree: %w", err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("getting HEAD: %w", err)
+	}
+
+	// Create the branch if it doesn't exist yet, or reset it to HEAD if it
+	// does.
+	branchRef := plumbing.NewBranchReferenceName(opts.Branch)
+	err = repo.Storer.SetReference(plumbing.NewHashReference(branchRef, head.Hash()))
+	if err != nil {
+		return nil, fmt.Errorf("creating branch %s: %w", opts.Branch, err)
+	}
+
+	if !opts.Force {
+		status, err := worktree.Status()
+		if err != nil {
+			return nil, fmt.Errorf("getting worktree status: %w", err)
+		}
+		if !status.IsClean() {
+			return nil, fmt.Errorf("worktree has uncommitted changes, refusing to check out branch %s (set Options.Force to discard them)", opts.Branch)
+		}
+	}
+
+	err = worktree.Checkout(&git.CheckoutOptions{Branch: branchRef, Force: opts.Force})
+	if err != nil {
+		return nil, fmt.Errorf("checking out branch %s: %w", opts.Branch, err)
+	}
+
+	return &Committer{
+		opts:     opts,
+		repo:     repo,
+		worktree: worktree,
+		files:    make(map[string]struct{}),
+	}, nil
+}
+
+// Track records that the file at path was rewritten as part of events, so it
+// is staged by a later call to Commit. path may be relative to the current
+// working directory (as filepath.Walk yields when given a relative root) or
+// absolute; it is resolved against the repository root either way.
+func (c *Committer) Track(path string, events []RebasedEvent) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("resolving absolute path for %s: %w", path, err)
+	}
+
+	root := c.worktree.Filesystem.Root()
+	relPath, err := filepath.Rel(root, absPath)
+	if err != nil {
+		return fmt.Errorf("resolving %s relative to repository root %s: %w", absPath, root, err)
+	}
+
+	c.files[relPath] = struct{}{}
+	c.events = append(c.events, events...)
+
+	return nil
+}
+
+// Commit stages the tracked files and creates a commit summarizing the
+// rebases, notifying opts.Notifier about each of them. It is a no-op if no
+// files were tracked. when is used as the author/committer timestamp, so the
+// commit is reproducible in tests rather than depending on time.Now().
+func (c *Committer) Commit(ctx context.Context, when time.Time) error {
+	if len(c.files) == 0 {
+		return nil
+	}
+
+	for path := range c.files {
+		if _, err := c.worktree.Add(path); err != nil {
+			return fmt.Errorf("staging %s: %w", path, err)
+		}
+	}
+
+	// Author/Committer are set explicitly rather than left for go-git to
+	// fall back to ambient git config, which is typically absent in the CI
+	// environments this feature targets and would otherwise fail the commit
+	// with ErrMissingAuthor.
+	signature := &object.Signature{
+		Name:  c.opts.AuthorName,
+		Email: c.opts.AuthorEmail,
+		When:  when,
+	}
+
+	_, err := c.worktree.Commit(c.commitMessage(), &git.CommitOptions{
+		Author:    signature,
+		Committer: signature,
+	})
+	if err != nil {
+		return fmt.Errorf("committing: %w", err)
+	}
+
+	return c.notify(ctx)
+}
+
+func (c *Committer) commitMessage() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "stacker: rebase %d image(s)\n\n", len(c.events))
+	for _, event := range c.events {
+		fmt.Fprintf(&sb, "- %s: %s -> %s (%s)\n", event.Image, event.OldDigest, event.NewDigest, event.File)
+	}
+
+	return sb.String()
+}
+
+func (c *Committer) notify(ctx context.Context) error {
+	if c.opts.Notifier == nil {
+		return nil
+	}
+
+	var errs error
+	for _, event := range c.events {
+		if err := c.opts.Notifier.Rebased(ctx, event); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("notifying about rebase of %s: %w", event.Image, err))
+		}
+	}
+
+	return errs
+}
+
+// Push pushes the committed branch to opts.Remote, authenticating via an SSH
+// agent if SSH_AUTH_SOCK is set, or otherwise via HTTP basic auth with a
+// GITHUB_TOKEN/GIT_TOKEN token as the password.
+func (c *Committer) Push(ctx context.Context) error {
+	auth, err := pushAuth()
+	if err != nil {
+		return fmt.Errorf("configuring auth: %w", err)
+	}
+
+	branchRef := plumbing.NewBranchReferenceName(c.opts.Branch)
+	err = c.repo.PushContext(ctx, &git.PushOptions{
+		RemoteName: c.opts.Remote,
+		RefSpecs:   []config.RefSpec{config.RefSpec(fmt.Sprintf("%s:%s", branchRef, branchRef))},
+		Auth:       auth,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return fmt.Errorf("pushing to %s: %w", c.opts.Remote, err)
+	}
+
+	return nil
+}
+
+func pushAuth() (transport.AuthMethod, error) {
+	if os.Getenv("SSH_AUTH_SOCK") != "" {
+		auth, err := ssh.NewSSHAgentAuth("git")
+		if err != nil {
+			return nil, fmt.Errorf("configuring ssh agent auth: %w", err)
+		}
+
+		return auth, nil
+	}
+
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		token = os.Getenv("GIT_TOKEN")
+	}
+	if token != "" {
+		return &http.BasicAuth{Username: "git", Password: token}, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,103 @@
+package gitops_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/networkteam/stacker/gitops"
+)
+
+func TestCommitter_Track_RelativePath(t *testing.T) {
+	dir := initRepoWithCommit(t)
+
+	// filepath.Walk with a relative directory argument (the common
+	// `stacker ./manifests` invocation) yields paths relative to the current
+	// working directory, not absolute ones.
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer func() { _ = os.Chdir(cwd) }()
+
+	committer, err := gitops.Open(dir, gitops.Options{Branch: "stacker/rebase-test"})
+	require.NoError(t, err)
+
+	err = committer.Track("manifest.yaml", []gitops.RebasedEvent{
+		{File: "manifest.yaml", Image: "example/app", OldDigest: "sha256:old", NewDigest: "sha256:new"},
+	})
+	require.NoError(t, err)
+}
+
+func initRepoWithCommit(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "manifest.yaml"), []byte("key: value\n"), 0o644))
+	_, err = worktree.Add("manifest.yaml")
+	require.NoError(t, err)
+	_, err = worktree.Commit("initial", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+
+	return dir
+}
+
+func TestCommitter_Commit_DefaultAuthorWithoutAmbientGitConfig(t *testing.T) {
+	dir := initRepoWithCommit(t)
+
+	committer, err := gitops.Open(dir, gitops.Options{Branch: "stacker/rebase-test"})
+	require.NoError(t, err)
+
+	// Rewrite the file as a rebase would, so there is a change to commit.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "manifest.yaml"), []byte("key: rebased\n"), 0o644))
+
+	require.NoError(t, committer.Track(filepath.Join(dir, "manifest.yaml"), []gitops.RebasedEvent{
+		{File: "manifest.yaml", Image: "example/app", OldDigest: "sha256:old", NewDigest: "sha256:new"},
+	}))
+
+	err = committer.Commit(context.Background(), time.Unix(1700000000, 0))
+	require.NoError(t, err)
+
+	repo, err := git.PlainOpen(dir)
+	require.NoError(t, err)
+	head, err := repo.Head()
+	require.NoError(t, err)
+	commit, err := repo.CommitObject(head.Hash())
+	require.NoError(t, err)
+
+	assert.Equal(t, "stacker", commit.Author.Name)
+	assert.Equal(t, "stacker@localhost", commit.Author.Email)
+}
+
+func TestOpen_RefusesDirtyWorktree(t *testing.T) {
+	dir := initRepoWithCommit(t)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "manifest.yaml"), []byte("key: changed\n"), 0o644))
+
+	_, err := gitops.Open(dir, gitops.Options{Branch: "stacker/rebase-test"})
+	assert.Error(t, err)
+}
+
+func TestOpen_ForceDiscardsDirtyWorktree(t *testing.T) {
+	dir := initRepoWithCommit(t)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "manifest.yaml"), []byte("key: changed\n"), 0o644))
+
+	_, err := gitops.Open(dir, gitops.Options{Branch: "stacker/rebase-test", Force: true})
+	require.NoError(t, err)
+}
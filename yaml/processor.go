@@ -2,49 +2,93 @@ package yaml
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"strings"
 
 	"github.com/hashicorp/go-multierror"
+	"github.com/vmware-labs/yaml-jsonpath/pkg/yamlpath"
 	goyaml "gopkg.in/yaml.v3"
 )
 
+// Processor holds every document of a (possibly "---"-separated) YAML
+// stream, as produced by e.g. `helm template` or `kustomize build`.
 type Processor struct {
-	node *goyaml.Node
+	documents []*goyaml.Node
 }
 
 func NewProcessor(r io.Reader) (*Processor, error) {
 	dec := goyaml.NewDecoder(r)
-	var node goyaml.Node
-	if err := dec.Decode(&node); err != nil {
-		return nil, err
+
+	var documents []*goyaml.Node
+	for {
+		var node goyaml.Node
+		err := dec.Decode(&node)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		documents = append(documents, &node)
+	}
+	if len(documents) == 0 {
+		return nil, io.EOF
 	}
 
 	return &Processor{
-		node: &node,
+		documents: documents,
 	}, nil
 }
 
 func (p *Processor) Encode(w io.Writer) error {
 	enc := goyaml.NewEncoder(w)
 	enc.SetIndent(2)
-	return enc.Encode(p.node)
+
+	for _, document := range p.documents {
+		if err := enc.Encode(document); err != nil {
+			return err
+		}
+	}
+
+	return enc.Close()
 }
 
 type RebaseAnnotation struct {
 	Identifier string
 	Name       string
 	Tag        string
+	// TargetName and TargetTag are set when the annotation declares a
+	// separate push destination via a "target-name"/"target-tag" part, e.g.
+	// to keep the source tag immutable while writing the rebased digest to
+	// a "rebased" tag instead.
+	TargetName string
+	TargetTag  string
 
-	nameNode *goyaml.Node
-	tagNode  *goyaml.Node
+	nameNode       *goyaml.Node
+	tagNode        *goyaml.Node
+	targetNameNode *goyaml.Node
+	targetTagNode  *goyaml.Node
+	// singleNode is set instead of nameNode/tagNode when the annotation was
+	// discovered from a single scalar holding a full "repo/name:tag"
+	// reference, e.g. via a RebaseRule.ImagePath.
+	singleNode *goyaml.Node
 }
 
 func (a RebaseAnnotation) TagWithoutDigest() string {
-	tag := a.Tag
+	return stripDigest(a.Tag)
+}
+
+// TargetTagWithoutDigest returns the target tag without a trailing
+// "@<digest>", analogous to TagWithoutDigest.
+func (a RebaseAnnotation) TargetTagWithoutDigest() string {
+	return stripDigest(a.TargetTag)
+}
 
+func stripDigest(tag string) string {
 	idx := strings.IndexByte(tag, '@')
 	if idx >= 0 {
 		tag = tag[:idx]
@@ -53,8 +97,20 @@ func (a RebaseAnnotation) TagWithoutDigest() string {
 	return tag
 }
 
-// UpdateTagDigest updates the YAML node for the tag with a new digest.
+// UpdateTagDigest updates the YAML node for the tag with a new digest. If the
+// annotation declares a target tag, that node is updated instead of the
+// source tag node, so the source tag is left untouched.
 func (a *RebaseAnnotation) UpdateTagDigest(newDigest string) {
+	if a.singleNode != nil {
+		a.singleNode.SetString(a.Name + ":" + a.TagWithoutDigest() + "@" + newDigest)
+		return
+	}
+
+	if a.targetTagNode != nil {
+		a.targetTagNode.SetString(a.TargetTagWithoutDigest() + "@" + newDigest)
+		return
+	}
+
 	a.tagNode.SetString(a.TagWithoutDigest() + "@" + newDigest)
 }
 
@@ -64,7 +120,7 @@ func (p *Processor) FindRebaseAnnotations() ([]RebaseAnnotation, error) {
 	var annotations map[string]*RebaseAnnotation
 	var visitErr error
 
-	p.visitMappingScalarNodes(p.node, func(node *goyaml.Node) {
+	visit := func(node *goyaml.Node) {
 		comment := node.LineComment
 		if comment == "" {
 			return
@@ -120,20 +176,180 @@ func (p *Processor) FindRebaseAnnotations() ([]RebaseAnnotation, error) {
 		case "tag":
 			annotation.Tag = node.Value
 			annotation.tagNode = node
+		case "target-name":
+			annotation.TargetName = node.Value
+			annotation.targetNameNode = node
+		case "target-tag":
+			annotation.TargetTag = node.Value
+			annotation.targetTagNode = node
 		default:
-			visitErr = multierror.Append(visitErr, fmt.Errorf("invalid part %q in $rebase annotation of line %d, expected \"name\" or \"tag\"", part, node.Line))
+			visitErr = multierror.Append(visitErr, fmt.Errorf("invalid part %q in $rebase annotation of line %d, expected \"name\", \"tag\", \"target-name\" or \"target-tag\"", part, node.Line))
 			return
 		}
-	})
+	}
+
+	for _, document := range p.documents {
+		p.visitMappingScalarNodes(document, visit)
+	}
 
 	var result []RebaseAnnotation
 	for _, annotation := range annotations {
+		// A target-name pointing at a different repository than the source
+		// has nowhere to record the pushed digest unless a target-tag
+		// annotation is also present: UpdateTagDigest would otherwise fall
+		// back to rewriting the source tag node, which was never pushed to.
+		if annotation.TargetName != "" && annotation.TargetName != annotation.Name && annotation.targetTagNode == nil {
+			visitErr = multierror.Append(visitErr, fmt.Errorf("annotation %q sets target-name to a different repository than name, but has no target-tag annotation to record the pushed digest", annotation.Identifier))
+			continue
+		}
+
 		result = append(result, *annotation)
 	}
 
 	return result, visitErr
 }
 
+// RebaseConfig declares JSONPath-based rules for discovering rebase
+// annotations without relying on inline YAML comments, which is useful for
+// generated manifests (Helm output, `kustomize build`, etc.). It is typically
+// loaded once from a `stacker.yaml` file and reused across all walked files.
+type RebaseConfig struct {
+	Rules []RebaseRule `yaml:"rules"`
+}
+
+// RebaseRule resolves a single image reference via JSONPath. Set both
+// NamePath and TagPath to target separate scalar nodes (e.g. `image` and
+// `tag` keys), or set ImagePath alone to target a single scalar holding a
+// full "repo/name:tag" reference, which is split into name and tag
+// internally.
+type RebaseRule struct {
+	Identifier string `yaml:"identifier"`
+	NamePath   string `yaml:"namePath"`
+	TagPath    string `yaml:"tagPath"`
+	ImagePath  string `yaml:"imagePath"`
+}
+
+// FindRebaseAnnotationsFromConfig resolves rebase annotations using the
+// JSONPath rules in cfg instead of inline YAML comments. A rule is resolved
+// against every document in the stream independently (Helm/kustomize output
+// is usually "---"-separated and a rule may match in more than one of them),
+// and is silently skipped for any document whose path(s) do not match
+// anything, so the same config can be shared across files with differing
+// structure.
+func (p *Processor) FindRebaseAnnotationsFromConfig(cfg RebaseConfig) ([]RebaseAnnotation, error) {
+	var result []RebaseAnnotation
+	var visitErr error
+
+	for _, rule := range cfg.Rules {
+		annotations, err := p.resolveRebaseRule(rule)
+		if err != nil {
+			visitErr = multierror.Append(visitErr, fmt.Errorf("resolving rule %q: %w", rule.Identifier, err))
+			continue
+		}
+		result = append(result, annotations...)
+	}
+
+	return result, visitErr
+}
+
+func (p *Processor) resolveRebaseRule(rule RebaseRule) ([]RebaseAnnotation, error) {
+	if rule.ImagePath == "" && (rule.NamePath == "" || rule.TagPath == "") {
+		return nil, errors.New("rule must set either imagePath, or both namePath and tagPath")
+	}
+
+	var result []RebaseAnnotation
+
+	for _, document := range p.documents {
+		if rule.ImagePath != "" {
+			node, found, err := p.findScalarNode(document, rule.ImagePath)
+			if err != nil {
+				return nil, fmt.Errorf("resolving imagePath: %w", err)
+			}
+			if !found {
+				continue
+			}
+
+			name, tag, ok := splitImageReference(node.Value)
+			if !ok {
+				return nil, fmt.Errorf("could not split image reference %q into name and tag", node.Value)
+			}
+
+			result = append(result, RebaseAnnotation{
+				Identifier: rule.Identifier,
+				Name:       name,
+				Tag:        tag,
+				singleNode: node,
+			})
+			continue
+		}
+
+		nameNode, nameFound, err := p.findScalarNode(document, rule.NamePath)
+		if err != nil {
+			return nil, fmt.Errorf("resolving namePath: %w", err)
+		}
+		tagNode, tagFound, err := p.findScalarNode(document, rule.TagPath)
+		if err != nil {
+			return nil, fmt.Errorf("resolving tagPath: %w", err)
+		}
+		if !nameFound || !tagFound {
+			continue
+		}
+
+		result = append(result, RebaseAnnotation{
+			Identifier: rule.Identifier,
+			Name:       nameNode.Value,
+			Tag:        tagNode.Value,
+			nameNode:   nameNode,
+			tagNode:    tagNode,
+		})
+	}
+
+	return result, nil
+}
+
+// findScalarNode resolves path to a single scalar node within document. found
+// is false if the path matched nothing.
+func (p *Processor) findScalarNode(document *goyaml.Node, path string) (node *goyaml.Node, found bool, err error) {
+	parsedPath, err := yamlpath.NewPath(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("parsing path: %w", err)
+	}
+
+	matchedNodes, err := parsedPath.Find(document)
+	if err != nil {
+		return nil, false, fmt.Errorf("finding node: %w", err)
+	}
+
+	if len(matchedNodes) == 0 {
+		return nil, false, nil
+	}
+	if len(matchedNodes) > 1 {
+		return nil, false, errors.New("multiple nodes matched path")
+	}
+
+	matchedNode := matchedNodes[0]
+	if matchedNode.Kind != goyaml.ScalarNode {
+		return nil, false, fmt.Errorf("expected scalar node, got kind %d (at %d:%d)", matchedNode.Kind, matchedNode.Line, matchedNode.Column)
+	}
+
+	return matchedNode, true, nil
+}
+
+// splitImageReference splits a "repo/name:tag" reference into its name and
+// tag. A colon in a registry host:port prefix is not mistaken for the tag
+// separator, as only the last path segment is searched.
+func splitImageReference(ref string) (name, tag string, ok bool) {
+	tagSearchStart := strings.LastIndexByte(ref, '/') + 1
+
+	colonIdx := strings.IndexByte(ref[tagSearchStart:], ':')
+	if colonIdx < 0 {
+		return "", "", false
+	}
+	colonIdx += tagSearchStart
+
+	return ref[:colonIdx], ref[colonIdx+1:], true
+}
+
 func (p *Processor) visitMappingScalarNodes(node *goyaml.Node, f func(node *goyaml.Node)) {
 	if node.Kind == goyaml.DocumentNode {
 		p.visitMappingScalarNodes(node.Content[0], f)
@@ -0,0 +1,60 @@
+package yaml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitImageReference(t *testing.T) {
+	tests := []struct {
+		name     string
+		ref      string
+		wantName string
+		wantTag  string
+		wantOk   bool
+	}{
+		{
+			name:     "simple repo and tag",
+			ref:      "my.registry.com/project/app:1.2.3",
+			wantName: "my.registry.com/project/app",
+			wantTag:  "1.2.3",
+			wantOk:   true,
+		},
+		{
+			name:     "registry host with port is not mistaken for the tag separator",
+			ref:      "my.registry.com:5000/project/app:1.2.3",
+			wantName: "my.registry.com:5000/project/app",
+			wantTag:  "1.2.3",
+			wantOk:   true,
+		},
+		{
+			name:     "tag with a trailing digest is kept intact",
+			ref:      "my.registry.com/project/app:1.2.3@sha256:d7500ff35777c1835490fb5d4bd5283236c9d18cdc59858c3203eda82abab412",
+			wantName: "my.registry.com/project/app",
+			wantTag:  "1.2.3@sha256:d7500ff35777c1835490fb5d4bd5283236c9d18cdc59858c3203eda82abab412",
+			wantOk:   true,
+		},
+		{
+			name:   "no tag separator",
+			ref:    "my.registry.com/project/app",
+			wantOk: false,
+		},
+		{
+			name:   "registry host with port but no tag separator",
+			ref:    "my.registry.com:5000/project/app",
+			wantOk: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, tag, ok := splitImageReference(tt.ref)
+			assert.Equal(t, tt.wantOk, ok)
+			if tt.wantOk {
+				assert.Equal(t, tt.wantName, name)
+				assert.Equal(t, tt.wantTag, tag)
+			}
+		})
+	}
+}
@@ -85,3 +85,142 @@ func TestProcessor_FindRebaseAnnotations_InvalidRebasePart(t *testing.T) {
 	_, err := processor.FindRebaseAnnotations()
 	assert.Error(t, err)
 }
+
+func TestProcessor_FindRebaseAnnotations_TargetNameDifferentRepoWithTargetTag(t *testing.T) {
+	r := strings.NewReader(`
+app:
+  image: my.registry.com/project/app # {"$rebase": "my-app:name"}
+  tag: 1.2.3 # {"$rebase": "my-app:tag"}
+  targetImage: other.registry.com/project/app # {"$rebase": "my-app:target-name"}
+  targetTag: rebased # {"$rebase": "my-app:target-tag"}
+`)
+	processor, _ := yaml.NewProcessor(r)
+	annotations, err := processor.FindRebaseAnnotations()
+	require.NoError(t, err)
+	require.Len(t, annotations, 1)
+	assert.Equal(t, "other.registry.com/project/app", annotations[0].TargetName)
+}
+
+func TestProcessor_FindRebaseAnnotations_MultiDocument(t *testing.T) {
+	r := strings.NewReader(`
+app:
+  image: my.registry.com/project/app # {"$rebase": "my-app:name"}
+  tag: 1.2.3 # {"$rebase": "my-app:tag"}
+---
+worker:
+  image: my.registry.com/project/worker # {"$rebase": "my-worker:name"}
+  tag: 4.5.6 # {"$rebase": "my-worker:tag"}
+`)
+	processor, err := yaml.NewProcessor(r)
+	require.NoError(t, err)
+
+	annotations, err := processor.FindRebaseAnnotations()
+	require.NoError(t, err)
+	require.Len(t, annotations, 2)
+
+	byIdentifier := make(map[string]yaml.RebaseAnnotation)
+	for _, annotation := range annotations {
+		byIdentifier[annotation.Identifier] = annotation
+	}
+
+	assert.Equal(t, "my.registry.com/project/app", byIdentifier["my-app"].Name)
+	assert.Equal(t, "my.registry.com/project/worker", byIdentifier["my-worker"].Name)
+}
+
+func TestProcessor_Encode_MultiDocument(t *testing.T) {
+	r := strings.NewReader("key: value\n---\nother: value\n")
+	processor, err := yaml.NewProcessor(r)
+	require.NoError(t, err)
+
+	var sb strings.Builder
+	require.NoError(t, processor.Encode(&sb))
+	assert.Equal(t, "key: value\n---\nother: value\n", sb.String())
+}
+
+func TestProcessor_FindRebaseAnnotations_TargetNameDifferentRepoWithoutTargetTag(t *testing.T) {
+	r := strings.NewReader(`
+app:
+  image: my.registry.com/project/app # {"$rebase": "my-app:name"}
+  tag: 1.2.3 # {"$rebase": "my-app:tag"}
+  targetImage: other.registry.com/project/app # {"$rebase": "my-app:target-name"}
+`)
+	processor, _ := yaml.NewProcessor(r)
+	annotations, err := processor.FindRebaseAnnotations()
+	assert.Error(t, err)
+	assert.Empty(t, annotations)
+}
+
+func TestProcessor_FindRebaseAnnotationsFromConfig_ImagePath(t *testing.T) {
+	r := strings.NewReader(`
+app:
+  image: my.registry.com/project/app:1.2.3
+`)
+	processor, err := yaml.NewProcessor(r)
+	require.NoError(t, err)
+
+	cfg := yaml.RebaseConfig{Rules: []yaml.RebaseRule{
+		{Identifier: "my-app", ImagePath: "$.app.image"},
+	}}
+
+	annotations, err := processor.FindRebaseAnnotationsFromConfig(cfg)
+	require.NoError(t, err)
+	require.Len(t, annotations, 1)
+	assert.Equal(t, "my-app", annotations[0].Identifier)
+	assert.Equal(t, "my.registry.com/project/app", annotations[0].Name)
+	assert.Equal(t, "1.2.3", annotations[0].Tag)
+}
+
+func TestProcessor_FindRebaseAnnotationsFromConfig_NameAndTagPath(t *testing.T) {
+	r := strings.NewReader(`
+app:
+  image: my.registry.com/project/app
+  tag: 1.2.3
+`)
+	processor, err := yaml.NewProcessor(r)
+	require.NoError(t, err)
+
+	cfg := yaml.RebaseConfig{Rules: []yaml.RebaseRule{
+		{Identifier: "my-app", NamePath: "$.app.image", TagPath: "$.app.tag"},
+	}}
+
+	annotations, err := processor.FindRebaseAnnotationsFromConfig(cfg)
+	require.NoError(t, err)
+	require.Len(t, annotations, 1)
+	assert.Equal(t, "my.registry.com/project/app", annotations[0].Name)
+	assert.Equal(t, "1.2.3", annotations[0].Tag)
+}
+
+func TestProcessor_FindRebaseAnnotationsFromConfig_MultipleMatchesError(t *testing.T) {
+	r := strings.NewReader(`
+apps:
+  - image: my.registry.com/project/app:1.2.3
+  - image: my.registry.com/project/worker:4.5.6
+`)
+	processor, err := yaml.NewProcessor(r)
+	require.NoError(t, err)
+
+	cfg := yaml.RebaseConfig{Rules: []yaml.RebaseRule{
+		{Identifier: "my-app", ImagePath: "$.apps[*].image"},
+	}}
+
+	annotations, err := processor.FindRebaseAnnotationsFromConfig(cfg)
+	assert.Error(t, err)
+	assert.Empty(t, annotations)
+}
+
+func TestProcessor_FindRebaseAnnotationsFromConfig_NoMatchIsSkipped(t *testing.T) {
+	r := strings.NewReader(`
+app:
+  image: my.registry.com/project/app:1.2.3
+`)
+	processor, err := yaml.NewProcessor(r)
+	require.NoError(t, err)
+
+	cfg := yaml.RebaseConfig{Rules: []yaml.RebaseRule{
+		{Identifier: "missing", ImagePath: "$.nope.image"},
+	}}
+
+	annotations, err := processor.FindRebaseAnnotationsFromConfig(cfg)
+	require.NoError(t, err)
+	assert.Empty(t, annotations)
+}